@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// retryPacer retries idempotent-safe HTTP failures (5xx, 429, connection
+// reset) with exponential backoff and jitter, so a brief Paperless restart
+// doesn't cost the user a scan. A retry is only attempted while body has not
+// yet started streaming, tracked via the body's bodyStarted flag, since a
+// partially-sent multipart upload can't be safely replayed.
+type retryPacer struct {
+	minDelay   time.Duration
+	maxDelay   time.Duration
+	decay      float64
+	maxRetries int
+}
+
+// defaultRetryPacer matches typical guidance for talking to a flaky
+// dependency: short initial backoff, capped at 2s, doubling each attempt.
+var defaultRetryPacer = retryPacer{
+	minDelay:   10 * time.Millisecond,
+	maxDelay:   2 * time.Second,
+	decay:      2,
+	maxRetries: 5,
+}
+
+// trackedBody wraps an io.ReadCloser so callers can tell, after a failed
+// request, whether the Transport ever read from it. If it hasn't, the same
+// request can be retried without resending (or losing) any bytes. Close is
+// forwarded to the wrapped reader unchanged: the Transport always closes a
+// request body by the time RoundTrip returns (including when it abandons an
+// in-flight request, e.g. on an early 413), and the copy goroutine feeding
+// the pipe is relying on that Close to unblock a pending Write.
+type trackedBody struct {
+	r       io.ReadCloser
+	started bool
+	closed  bool
+}
+
+func (t *trackedBody) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.started = true
+	}
+	return n, err
+}
+
+func (t *trackedBody) Close() error {
+	t.closed = true
+	return t.r.Close()
+}
+
+// Do executes req via client, retrying per p when the failure is
+// idempotent-safe and req's body (tracked by body) hasn't started sending.
+// It logs a structured event for every retry.
+func (p retryPacer) Do(ctx context.Context, client *http.Client, req *http.Request, body *trackedBody) (*http.Response, error) {
+	start := time.Now()
+
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		resp, err = client.Do(req)
+
+		if !p.shouldRetry(attempt, err, resp) {
+			return resp, err
+		}
+
+		if body != nil && (body.started || body.closed) {
+			logger.Warn("Not retrying Paperless request: body already sent or closed", "attempt", attempt)
+			return resp, err
+		}
+
+		delay := p.backoff(attempt)
+		if resp != nil {
+			if ra := retryAfterDelay(resp); ra > 0 {
+				delay = ra
+			}
+			resp.Body.Close()
+		}
+
+		logger.Warn("Retrying Paperless request",
+			"attempt", attempt+1,
+			"max_retries", p.maxRetries,
+			"delay", delay,
+			"elapsed", time.Since(start),
+			"error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		}
+	}
+}
+
+// shouldRetry reports whether another attempt is worth making.
+func (p retryPacer) shouldRetry(attempt int, err error, resp *http.Response) bool {
+	if attempt >= p.maxRetries {
+		return false
+	}
+
+	if err != nil {
+		return isRetryableError(err)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff computes the jittered exponential delay for a given attempt
+// number, full-jittered between 0 and the theoretical max for that attempt.
+func (p retryPacer) backoff(attempt int) time.Duration {
+	theoretical := float64(p.minDelay) * math.Pow(p.decay, float64(attempt))
+	if theoretical > float64(p.maxDelay) {
+		theoretical = float64(p.maxDelay)
+	}
+
+	return time.Duration(rand.Int63n(int64(theoretical) + 1))
+}
+
+// isRetryableError reports whether err looks like a transient network
+// failure rather than a permanent one. Connection reset and timeouts are
+// the obvious transient cases; a dial-phase failure (e.g. connection
+// refused) is also treated as transient, since that's exactly what a client
+// sees while Paperless is restarting and not yet accepting connections.
+func isRetryableError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfterDelay parses the Retry-After header, if present, as either a
+// number of seconds or an HTTP-date, returning 0 when absent or unparsable.
+func retryAfterDelay(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+
+	return 0
+}
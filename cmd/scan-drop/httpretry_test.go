@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPacerBackoffBounds(t *testing.T) {
+	p := retryPacer{minDelay: 10 * time.Millisecond, maxDelay: 2 * time.Second, decay: 2}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(attempt)
+			if d < 0 {
+				t.Fatalf("backoff(%d) = %v, want >= 0", attempt, d)
+			}
+			if d > p.maxDelay {
+				t.Fatalf("backoff(%d) = %v, want <= maxDelay %v", attempt, d, p.maxDelay)
+			}
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"eof", io.EOF, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"connection refused", syscall.ECONNREFUSED, true},
+		{"timeout", &net.DNSError{IsTimeout: true}, true},
+		{"permanent dns failure", &net.DNSError{IsTimeout: false}, false},
+		{"dial-phase connection refused (e.g. Paperless still restarting)", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableError(tc.err); got != tc.want {
+				t.Errorf("isRetryableError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrackedBodyCloseForwardsAndMarksClosed(t *testing.T) {
+	inner := &closeTrackingReader{}
+	body := &trackedBody{r: inner}
+
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close() returned unexpected error: %v", err)
+	}
+	if !inner.closed {
+		t.Fatal("Close() did not forward to the wrapped reader")
+	}
+	if !body.closed {
+		t.Fatal("Close() did not set body.closed")
+	}
+}
+
+type closeTrackingReader struct {
+	closed bool
+}
+
+func (r *closeTrackingReader) Read(p []byte) (int, error) { return 0, io.EOF }
+func (r *closeTrackingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := retryPacer{maxRetries: 2}
+
+	if p.shouldRetry(2, syscall.ECONNRESET, nil) {
+		t.Error("shouldRetry at maxRetries should be false regardless of error")
+	}
+	if !p.shouldRetry(0, syscall.ECONNRESET, nil) {
+		t.Error("shouldRetry should be true for a retryable error within maxRetries")
+	}
+	if p.shouldRetry(0, errors.New("connection refused"), nil) {
+		t.Error("shouldRetry should be false for a non-retryable error")
+	}
+}
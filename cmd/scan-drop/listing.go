@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// documentListPageSize caps how many documents a single LIST/NLST/MLSD
+// fetches from Paperless-ngx; scanner directories are small enough in
+// practice that pagination isn't worth the added round trips.
+const documentListPageSize = 200
+
+// paperlessDocument is the subset of Paperless-ngx's document list fields
+// needed to render a synthetic directory entry.
+type paperlessDocument struct {
+	ID               int       `json:"id"`
+	Title            string    `json:"title"`
+	Created          time.Time `json:"created"`
+	OriginalFileName string    `json:"original_file_name"`
+}
+
+type documentListResponse struct {
+	Results []paperlessDocument `json:"results"`
+}
+
+// namedResource is the subset of Paperless-ngx's tag/correspondent list
+// fields needed to resolve a CWD path segment (e.g. "invoices") to the ID
+// its filters expect (e.g. tags__id=3).
+type namedResource struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type namedResourceListResponse struct {
+	Results []namedResource `json:"results"`
+}
+
+// documentsForCWD resolves cwd to a Paperless filter and fetches the
+// matching documents, most recent first. apiToken, when non-empty, is the
+// authenticated user's Paperless-ngx token and takes priority over the
+// server's default so one user's listing can't surface another's documents.
+func (s *FTPServer) documentsForCWD(ctx context.Context, apiToken, cwd string) ([]paperlessDocument, error) {
+	filter, err := s.resolveCWDFilter(ctx, apiToken, cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fetchDocuments(ctx, apiToken, filter)
+}
+
+// fetchDocuments queries Paperless-ngx's /api/documents/ endpoint, applying
+// filter on top of the standard newest-first ordering.
+func (s *FTPServer) fetchDocuments(ctx context.Context, apiToken string, filter url.Values) ([]paperlessDocument, error) {
+	query := url.Values{}
+	query.Set("ordering", "-created")
+	query.Set("page_size", strconv.Itoa(documentListPageSize))
+	for key, values := range filter {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/api/documents/?%s", strings.TrimRight(s.paperlessURL, "/"), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	token := s.paperlessToken
+	if apiToken != "" {
+		token = apiToken
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paperless documents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("paperless API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed documentListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode paperless documents response: %w", err)
+	}
+
+	return parsed.Results, nil
+}
+
+// resolveCWDFilter maps CWD segments like "/tag/invoices" or
+// "/correspondent/acme" to the Paperless-ngx query filter they imply. CWDs
+// that don't match a known prefix return an empty (unfiltered) filter.
+func (s *FTPServer) resolveCWDFilter(ctx context.Context, apiToken, cwd string) (url.Values, error) {
+	filter := url.Values{}
+
+	segments := strings.Split(strings.Trim(cwd, "/"), "/")
+	if len(segments) < 2 || segments[1] == "" {
+		return filter, nil
+	}
+
+	var resource, field string
+	switch segments[0] {
+	case "tag":
+		resource, field = "tags", "tags__id"
+	case "correspondent":
+		resource, field = "correspondents", "correspondent__id"
+	default:
+		return filter, nil
+	}
+
+	id, err := s.lookupNamedResourceID(ctx, apiToken, resource, segments[1])
+	if err != nil {
+		return nil, err
+	}
+	if id > 0 {
+		filter.Set(field, strconv.Itoa(id))
+	}
+
+	return filter, nil
+}
+
+// lookupNamedResourceID finds the ID Paperless-ngx assigned to name within
+// resource (e.g. "tags" or "correspondents"), returning 0 if there's no
+// exact match.
+func (s *FTPServer) lookupNamedResourceID(ctx context.Context, apiToken, resource, name string) (int, error) {
+	endpoint := fmt.Sprintf("%s/api/%s/?name__iexact=%s", strings.TrimRight(s.paperlessURL, "/"), resource, url.QueryEscape(name))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	token := s.paperlessToken
+	if apiToken != "" {
+		token = apiToken
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up %s %q: %w", resource, name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("paperless API returned status %d while looking up %s %q", resp.StatusCode, resource, name)
+	}
+
+	var parsed namedResourceListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return 0, fmt.Errorf("failed to decode %s lookup response: %w", resource, err)
+	}
+
+	if len(parsed.Results) == 0 {
+		return 0, nil
+	}
+
+	return parsed.Results[0].ID, nil
+}
+
+// documentDisplayName renders the synthetic filename for a document: its
+// title plus the extension of the file it was created from.
+func documentDisplayName(doc paperlessDocument) string {
+	ext := filepath.Ext(doc.OriginalFileName)
+	if ext == "" {
+		return doc.Title
+	}
+	return doc.Title + ext
+}
+
+// formatUnixListLine renders one LIST line in the UNIX ls -l style most FTP
+// clients expect. Paperless-ngx's document list doesn't expose a byte size,
+// so it's best-effort reported as 0.
+func formatUnixListLine(doc paperlessDocument) string {
+	return fmt.Sprintf("-rw-r--r-- 1 paperless paperless %d %s %s",
+		0, doc.Created.Format("Jan 02 15:04"), documentDisplayName(doc))
+}
+
+// formatMlsdLine renders one MLSD line as RFC 3659 facts followed by the
+// filename.
+func formatMlsdLine(doc paperlessDocument) string {
+	return fmt.Sprintf("type=file;size=%d;modify=%s; %s",
+		0, doc.Created.UTC().Format("20060102150405"), documentDisplayName(doc))
+}
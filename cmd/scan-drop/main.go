@@ -1,15 +1,13 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"log/slog"
-	"mime/multipart"
 	"net"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,23 +15,80 @@ import (
 
 var logger *slog.Logger
 
+// defaultTransferTimeout bounds how long a single STOR upload may take end
+// to end, guarding against a stalled data connection or a wedged Paperless
+// backend holding a goroutine open forever.
+const defaultTransferTimeout = 10 * time.Minute
+
 // Config holds the runtime configuration for the FTP server
 type Config struct {
-	Port            int
-	LogLevel        slog.Level
-	PaperlessURL    string
-	PaperlessToken  string
-	HTTPTimeout     time.Duration
+	Port           int
+	LogLevel       slog.Level
+	PaperlessURL   string
+	PaperlessToken string
+	HTTPTimeout    time.Duration
+
+	// FTPSImplicitPort, when non-zero, starts a second listener that wraps
+	// every accepted connection in TLS before the FTP protocol starts
+	// (implicit FTPS, historically port 990). Leave at 0 to disable.
+	FTPSImplicitPort int
+	// TLSCertFile and TLSKeyFile are the PEM certificate/key pair used for
+	// both implicit FTPS and explicit AUTH TLS/AUTH SSL. FTPS is disabled
+	// when either is empty.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSMinVersion is a crypto/tls version constant (e.g. tls.VersionTLS12).
+	TLSMinVersion uint16
+	// TLSClientCAFile, when set, enables mutual TLS by requiring and
+	// verifying a client certificate signed by this CA bundle.
+	TLSClientCAFile string
+
+	// PasvPortMin and PasvPortMax bound the port range PASV/EPSV allocate
+	// data-connection listeners from, so it can be punched through a
+	// firewall.
+	PasvPortMin int
+	PasvPortMax int
+	// PasvAdvertisedIP is the IP address announced in the PASV 227 reply.
+	// Required for NATed deployments, where the listening socket's address
+	// isn't reachable from outside.
+	PasvAdvertisedIP string
+
+	// UsersFile points at a YAML or JSON file describing allowed FTP users
+	// and their default Paperless-ngx metadata. When empty, and UsersJSON is
+	// also empty, USER/PASS accept any credentials and uploads carry no
+	// per-user metadata, matching the server's original behavior.
+	UsersFile string
+	// UsersJSON is an inline JSON-encoded Users config, for deployments that
+	// prefer an environment variable over mounting a file.
+	UsersJSON string
+
+	// FTPIdleTimeout bounds how long the control connection may wait for the
+	// next command before it's dropped.
+	FTPIdleTimeout time.Duration
+	// FTPControlTimeout bounds how long a single control-channel
+	// request/response round trip may take for commands that don't open a
+	// data connection (STOR/LIST get their own, longer-lived deadline since
+	// a transfer can legitimately run well past this).
+	FTPControlTimeout time.Duration
+	// FTPDataTimeout bounds the data-channel handshake: how long PASV/EPSV
+	// wait for the client to connect, and how long an active-mode PORT/EPRT
+	// dial may take.
+	FTPDataTimeout time.Duration
 }
 
 // NewConfig creates a new Config with default values
 func NewConfig() *Config {
 	return &Config{
-		Port:           2121,
-		LogLevel:       slog.LevelInfo,
-		PaperlessURL:   "http://localhost:8000",
-		PaperlessToken: "",
-		HTTPTimeout:    30 * time.Second,
+		Port:              2121,
+		LogLevel:          slog.LevelInfo,
+		PaperlessURL:      "http://localhost:8000",
+		PaperlessToken:    "",
+		HTTPTimeout:       30 * time.Second,
+		FTPSImplicitPort:  0,
+		TLSMinVersion:     tls.VersionTLS12,
+		FTPIdleTimeout:    5 * time.Minute,
+		FTPControlTimeout: 30 * time.Second,
+		FTPDataTimeout:    30 * time.Second,
 	}
 }
 
@@ -76,13 +131,94 @@ func (c *Config) LoadFromEnv() {
 			c.LogLevel = slog.LevelError
 		}
 	}
+
+	// Load FTPS implicit-mode port from FTPS_IMPLICIT_PORT environment variable
+	if envImplicitPort := os.Getenv("FTPS_IMPLICIT_PORT"); envImplicitPort != "" {
+		if p, err := strconv.Atoi(envImplicitPort); err == nil {
+			c.FTPSImplicitPort = p
+		}
+	}
+
+	// Load TLS certificate/key paths from FTPS_CERT_FILE/FTPS_KEY_FILE
+	if envCertFile := os.Getenv("FTPS_CERT_FILE"); envCertFile != "" {
+		c.TLSCertFile = envCertFile
+	}
+	if envKeyFile := os.Getenv("FTPS_KEY_FILE"); envKeyFile != "" {
+		c.TLSKeyFile = envKeyFile
+	}
+
+	// Load minimum TLS version from FTPS_MIN_TLS_VERSION environment variable
+	if envMinVersion := os.Getenv("FTPS_MIN_TLS_VERSION"); envMinVersion != "" {
+		c.TLSMinVersion = parseTLSVersion(envMinVersion)
+	}
+
+	// Load client CA bundle for mutual TLS from FTPS_CLIENT_CA_FILE
+	if envClientCA := os.Getenv("FTPS_CLIENT_CA_FILE"); envClientCA != "" {
+		c.TLSClientCAFile = envClientCA
+	}
+
+	// Load passive-mode port range from PASV_PORT_MIN/PASV_PORT_MAX
+	if envPasvMin := os.Getenv("PASV_PORT_MIN"); envPasvMin != "" {
+		if p, err := strconv.Atoi(envPasvMin); err == nil {
+			c.PasvPortMin = p
+		}
+	}
+	if envPasvMax := os.Getenv("PASV_PORT_MAX"); envPasvMax != "" {
+		if p, err := strconv.Atoi(envPasvMax); err == nil {
+			c.PasvPortMax = p
+		}
+	}
+
+	// Load the externally reachable IP to advertise in PASV replies
+	if envAdvertisedIP := os.Getenv("PASV_ADVERTISED_IP"); envAdvertisedIP != "" {
+		c.PasvAdvertisedIP = envAdvertisedIP
+	}
+
+	// Load the Users config from USERS_FILE or, inline, from USERS_JSON
+	if envUsersFile := os.Getenv("USERS_FILE"); envUsersFile != "" {
+		c.UsersFile = envUsersFile
+	}
+	if envUsersJSON := os.Getenv("USERS_JSON"); envUsersJSON != "" {
+		c.UsersJSON = envUsersJSON
+	}
+
+	// Load connection timeouts from FTP_IDLE_TIMEOUT/FTP_CONTROL_TIMEOUT/FTP_DATA_TIMEOUT
+	if envIdleTimeout := os.Getenv("FTP_IDLE_TIMEOUT"); envIdleTimeout != "" {
+		if d, err := time.ParseDuration(envIdleTimeout); err == nil {
+			c.FTPIdleTimeout = d
+		}
+	}
+	if envControlTimeout := os.Getenv("FTP_CONTROL_TIMEOUT"); envControlTimeout != "" {
+		if d, err := time.ParseDuration(envControlTimeout); err == nil {
+			c.FTPControlTimeout = d
+		}
+	}
+	if envDataTimeout := os.Getenv("FTP_DATA_TIMEOUT"); envDataTimeout != "" {
+		if d, err := time.ParseDuration(envDataTimeout); err == nil {
+			c.FTPDataTimeout = d
+		}
+	}
 }
 
 type FTPServer struct {
-	listener       net.Listener
-	paperlessURL   string
-	paperlessToken string
-	httpClient     *http.Client
+	listener         net.Listener
+	implicitListener net.Listener
+	tlsConfig        *tls.Config
+	paperlessURL     string
+	paperlessToken   string
+	httpClient       *http.Client
+
+	pasvPortMin      int
+	pasvPortMax      int
+	pasvAdvertisedIP string
+
+	// userStore is nil when no Users config is configured, in which case
+	// USER/PASS accept any credentials as before.
+	userStore *UserStore
+
+	idleTimeout    time.Duration
+	controlTimeout time.Duration
+	dataTimeout    time.Duration
 }
 
 func NewFTPServer(config *Config) (*FTPServer, error) {
@@ -96,120 +232,235 @@ func NewFTPServer(config *Config) (*FTPServer, error) {
 		return nil, fmt.Errorf("PAPERLESS_TOKEN environment variable is required")
 	}
 
-	return &FTPServer{
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure FTPS: %w", err)
+	}
+
+	var userStore *UserStore
+	switch {
+	case config.UsersJSON != "":
+		userStore, err = LoadUserStoreFromJSON(config.UsersJSON)
+	case config.UsersFile != "":
+		userStore, err = LoadUserStore(config.UsersFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users config: %w", err)
+	}
+
+	server := &FTPServer{
 		listener:       listener,
+		tlsConfig:      tlsConfig,
 		paperlessURL:   config.PaperlessURL,
 		paperlessToken: config.PaperlessToken,
 		httpClient: &http.Client{
 			Timeout: config.HTTPTimeout,
 		},
-	}, nil
-}
-
-// handleLIST processes the LIST command for directory listings
-func (s *FTPServer) handleLIST(conn net.Conn, activeHost string, activePort int) {
-	s.send(conn, "150 Opening data connection")
+		pasvPortMin:      config.PasvPortMin,
+		pasvPortMax:      config.PasvPortMax,
+		pasvAdvertisedIP: config.PasvAdvertisedIP,
+		userStore:        userStore,
+		idleTimeout:      config.FTPIdleTimeout,
+		controlTimeout:   config.FTPControlTimeout,
+		dataTimeout:      config.FTPDataTimeout,
+	}
 
-	// Establish active connection using PORT/EPRT
-	if activeHost != "" && activePort > 0 {
-		dataConn, err := net.Dial("tcp", net.JoinHostPort(activeHost, strconv.Itoa(activePort)))
+	// Implicit FTPS wraps every accepted connection in TLS before the FTP
+	// control protocol begins, so it needs its own listener on its own port.
+	if tlsConfig != nil && config.FTPSImplicitPort > 0 {
+		rawListener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(config.FTPSImplicitPort)))
 		if err != nil {
-			s.send(conn, "425 Can't open data connection")
-			return
+			return nil, err
 		}
-		defer dataConn.Close()
-		// Return empty listing since files go directly to Paperless-ngx
+		server.implicitListener = tls.NewListener(rawListener, tlsConfig)
 	}
 
-	s.send(conn, "226 Transfer complete")
+	return server, nil
 }
 
-// handleSTOR processes the STOR command for file uploads
-func (s *FTPServer) handleSTOR(conn net.Conn, filename string, activeHost string, activePort int) {
-	s.send(conn, "150 Opening data connection")
+// handleLIST processes the LIST command for directory listings, rendering
+// Paperless-ngx documents matching cwd as UNIX ls -l style entries. open is
+// shared across PORT/EPRT/PASV/EPSV so this function doesn't need to know
+// which one the client used. user is nil when the server has no Users
+// config configured, in which case the listing uses the server's default
+// Paperless API token.
+func (s *FTPServer) handleLIST(conn net.Conn, open dataChannelOpener, user *User, cwd string) {
+	s.listDocuments(conn, open, user, cwd, formatUnixListLine)
+}
 
-	// Establish active connection using PORT/EPRT
-	if activeHost != "" && activePort > 0 {
-		dataConn, err := net.Dial("tcp", net.JoinHostPort(activeHost, strconv.Itoa(activePort)))
-		if err != nil {
-			s.send(conn, "425 Can't open data connection")
-			return
-		}
-		defer dataConn.Close()
+// handleNLST processes the NLST command, rendering one bare filename per
+// matching document instead of LIST's long format.
+func (s *FTPServer) handleNLST(conn net.Conn, open dataChannelOpener, user *User, cwd string) {
+	s.listDocuments(conn, open, user, cwd, documentDisplayName)
+}
 
-		// Read all file data into memory
-		fileData, err := io.ReadAll(dataConn)
-		if err != nil {
-			s.send(conn, "426 Connection closed; transfer aborted")
+// handleMLSD processes the MLSD command, rendering one matching document per
+// line as RFC 3659 facts.
+func (s *FTPServer) handleMLSD(conn net.Conn, open dataChannelOpener, user *User, cwd string) {
+	s.listDocuments(conn, open, user, cwd, formatMlsdLine)
+}
+
+// handleMLST processes the MLST command over the control connection (no
+// data channel involved), rendering RFC 3659 facts for a single entry: the
+// current directory when arg is empty, or the document within it named
+// arg otherwise.
+func (s *FTPServer) handleMLST(conn net.Conn, arg string, user *User, cwd string) {
+	if arg == "" || arg == "." {
+		s.send(conn, "250-Listing "+cwd)
+		s.send(conn, fmt.Sprintf(" type=cwdir; %s", cwd))
+		s.send(conn, "250 End")
+		return
+	}
+
+	apiToken := ""
+	if user != nil {
+		apiToken = user.APIToken
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.controlTimeout)
+	defer cancel()
+
+	docs, err := s.documentsForCWD(ctx, apiToken, cwd)
+	if err != nil {
+		logger.Error("Failed to resolve MLST target", "error", err, "cwd", cwd, "arg", arg)
+		s.send(conn, "450 Failed to retrieve listing")
+		return
+	}
+
+	for _, doc := range docs {
+		if documentDisplayName(doc) == arg {
+			s.send(conn, "250-Listing "+arg)
+			s.send(conn, " "+formatMlsdLine(doc))
+			s.send(conn, "250 End")
 			return
 		}
+	}
 
-		// Upload directly to Paperless-ngx
-		if err := s.uploadToPaperless(filename, fileData); err != nil {
-			logger.Error("Failed to upload to Paperless-ngx", "error", err, "filename", filename)
-			s.send(conn, "550 Upload failed")
-		} else {
-			s.send(conn, "226 Transfer complete")
-		}
-	} else {
+	s.send(conn, fmt.Sprintf("550 %s: No such file or directory", arg))
+}
+
+// listDocuments drives the shared LIST/NLST/MLSD flow: open the data
+// connection, query Paperless-ngx (as user, so one authenticated user can't
+// see another's documents) for the documents cwd maps to, and write one
+// render(doc) line per document.
+func (s *FTPServer) listDocuments(conn net.Conn, open dataChannelOpener, user *User, cwd string, render func(paperlessDocument) string) {
+	s.send(conn, "150 Opening data connection")
+
+	dataConn, err := open()
+	if err != nil {
 		s.send(conn, "425 Can't open data connection")
+		return
 	}
-}
+	defer dataConn.Close()
 
-// uploadToPaperless uploads a document to Paperless-ngx via REST API
-func (s *FTPServer) uploadToPaperless(filename string, fileData []byte) error {
+	// Bound how long a client may take to read the listing, so one that
+	// never drains the data connection can't block this goroutine forever.
+	dataConn.SetWriteDeadline(time.Now().Add(defaultTransferTimeout))
 
-	// Create multipart form
-	var buf bytes.Buffer
-	w := multipart.NewWriter(&buf)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTransferTimeout)
+	defer cancel()
 
-	// Add the document file
-	fw, err := w.CreateFormFile("document", filepath.Base(filename))
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+	apiToken := ""
+	if user != nil {
+		apiToken = user.APIToken
 	}
 
-	if _, err := fw.Write(fileData); err != nil {
-		return fmt.Errorf("failed to write file data: %w", err)
+	docs, err := s.documentsForCWD(ctx, apiToken, cwd)
+	if err != nil {
+		logger.Error("Failed to list Paperless-ngx documents", "error", err, "cwd", cwd)
+		s.send(conn, "451 Failed to retrieve listing")
+		return
 	}
 
-	// Close the multipart writer
-	if err := w.Close(); err != nil {
-		return fmt.Errorf("failed to close multipart writer: %w", err)
+	for _, doc := range docs {
+		if _, err := fmt.Fprintf(dataConn, "%s\r\n", render(doc)); err != nil {
+			s.send(conn, "426 Connection closed; transfer aborted")
+			return
+		}
 	}
 
-	// Create the HTTP request
-	url := fmt.Sprintf("%s/api/documents/post_document/", strings.TrimRight(s.paperlessURL, "/"))
-	req, err := http.NewRequest("POST", url, &buf)
+	s.send(conn, "226 Transfer complete")
+}
+
+// handleSTOR processes the STOR command for file uploads. open is shared
+// across PORT/EPRT/PASV/EPSV so this function doesn't need to know which one
+// the client used. user is nil when the server has no Users config
+// configured, in which case the upload carries no per-user metadata.
+func (s *FTPServer) handleSTOR(conn net.Conn, filename string, open dataChannelOpener, user *User, cwd string) {
+	s.send(conn, "150 Opening data connection")
+
+	dataConn, err := open()
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		s.send(conn, "425 Can't open data connection")
+		return
+	}
+	defer dataConn.Close()
+
+	// Bound how long a client may take to send the file, mirroring the
+	// upload ctx below: without this, a client that opens the data
+	// connection and never sends anything blocks the copy goroutine inside
+	// uploadToPaperless on src.Read() forever, since ctx cancellation has no
+	// effect on a plain net.Conn read.
+	dataConn.SetReadDeadline(time.Now().Add(defaultTransferTimeout))
+
+	// Stream the data connection straight into the Paperless-ngx upload
+	// instead of buffering the whole file in memory.
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTransferTimeout)
+	defer cancel()
+
+	var metadata UserMetadata
+	username, apiToken := "", ""
+	if user != nil {
+		username = user.Username
+		apiToken = user.APIToken
+		metadata = s.userStore.ResolveMetadata(user, cwd)
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", s.paperlessToken))
-
-	// Send the request
-	resp, err := s.httpClient.Do(req)
+	bytesUploaded, err := s.uploadToPaperless(ctx, filename, dataConn, username, apiToken, metadata)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		logger.Error("Failed to upload to Paperless-ngx", "error", err, "filename", filename, "bytes", bytesUploaded)
+		s.send(conn, "550 Upload failed")
+	} else {
+		logger.Info("Upload complete", "filename", filename, "bytes", bytesUploaded)
+		s.send(conn, "226 Transfer complete")
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("paperless API returned status %d: %s", resp.StatusCode, string(respBody))
+// wrapDataConn wraps a freshly dialed data connection in TLS when the client
+// has requested protected transfers via PBSZ 0 / PROT P. The same tlsConfig
+// used for the control channel is reused so the data channel can resume the
+// control channel's session, which some FTPS clients require.
+func (s *FTPServer) wrapDataConn(conn net.Conn, dataProtLevel string) (net.Conn, error) {
+	if dataProtLevel != "P" || s.tlsConfig == nil {
+		return conn, nil
 	}
 
-	logger.Info("Document uploaded to Paperless-ngx", "filename", filename, "status", resp.StatusCode)
-	return nil
+	tlsConn := tls.Server(conn, s.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("data channel TLS handshake failed: %w", err)
+	}
+
+	return tlsConn, nil
 }
 
 func (s *FTPServer) Start() {
 	logger.Info("FTP Server started", "port", s.listener.Addr().String(), "paperless_url", s.paperlessURL)
 
+	if s.implicitListener != nil {
+		logger.Info("Implicit FTPS listener started", "port", s.implicitListener.Addr().String())
+		go s.acceptLoop(s.implicitListener)
+	}
+
+	s.acceptLoop(s.listener)
+}
+
+// acceptLoop accepts connections from listener and hands each to
+// handleConnection in its own goroutine. It is used for both the plain FTP
+// listener and, when implicit FTPS is enabled, the TLS-wrapped one.
+func (s *FTPServer) acceptLoop(listener net.Listener) {
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			logger.Warn("Failed to accept connection", "error", err)
 			continue
@@ -225,7 +476,22 @@ func (s *FTPServer) handleConnection(conn net.Conn) {
 
 	var activeHost string
 	var activePort int
+	var pasvListener net.Listener
+	var pendingUsername string
+	var authenticatedUser *User
 	currentDir := "/"
+	// dataProtLevel tracks PROT: "C" (clear, default) or "P" (private/TLS).
+	dataProtLevel := "C"
+
+	// closePasvListener discards any passive listener left over from a PASV
+	// that was never followed by a transfer (e.g. the client retried PASV).
+	closePasvListener := func() {
+		if pasvListener != nil {
+			pasvListener.Close()
+			pasvListener = nil
+		}
+	}
+	defer closePasvListener()
 
 	for {
 		line, err := s.readLine(conn)
@@ -240,11 +506,41 @@ func (s *FTPServer) handleConnection(conn net.Conn) {
 
 		cmd := strings.ToUpper(parts[0])
 
+		// STOR/LIST open a data connection and can legitimately run well
+		// past the control-channel deadline, so they manage their own
+		// timeouts instead of inheriting this one.
+		if cmd == "STOR" || cmd == "LIST" || cmd == "NLST" || cmd == "MLSD" {
+			conn.SetDeadline(time.Time{})
+		} else {
+			conn.SetDeadline(time.Now().Add(s.controlTimeout))
+		}
+
 		switch cmd {
 		case "USER":
+			if len(parts) > 1 {
+				pendingUsername = parts[1]
+			}
 			s.send(conn, "331 User name okay, need password")
 
 		case "PASS":
+			if s.userStore == nil {
+				s.send(conn, "230 User logged in, proceed")
+				continue
+			}
+
+			password := ""
+			if len(parts) > 1 {
+				password = parts[1]
+			}
+
+			user, ok := s.userStore.Authenticate(pendingUsername, password)
+			if !ok {
+				logger.Warn("Authentication failed", "user", pendingUsername)
+				s.send(conn, "530 Not logged in")
+				continue
+			}
+
+			authenticatedUser = user
 			s.send(conn, "230 User logged in, proceed")
 
 		case "SYST":
@@ -263,17 +559,100 @@ func (s *FTPServer) handleConnection(conn net.Conn) {
 			s.send(conn, "250 Directory changed")
 
 		case "PASV":
-			s.send(conn, "502 Command not implemented")
+			closePasvListener()
+			listener, port, err := allocatePasvListener(s.pasvPortMin, s.pasvPortMax)
+			if err != nil {
+				logger.Warn("Failed to allocate PASV listener", "error", err)
+				s.send(conn, "425 Can't open passive connection")
+				continue
+			}
+			reply, err := formatPasvReply(s.pasvAdvertisedIP, port)
+			if err != nil {
+				logger.Error("Cannot enter passive mode", "error", err)
+				listener.Close()
+				s.send(conn, "502 PASV not supported: server is missing PASV_ADVERTISED_IP")
+				continue
+			}
+			pasvListener = listener
+			s.send(conn, reply)
+
+		case "EPSV":
+			closePasvListener()
+			listener, port, err := allocatePasvListener(s.pasvPortMin, s.pasvPortMax)
+			if err != nil {
+				logger.Warn("Failed to allocate EPSV listener", "error", err)
+				s.send(conn, "425 Can't open passive connection")
+				continue
+			}
+			pasvListener = listener
+			s.send(conn, formatEpsvReply(port))
 
 		case "LIST":
-			s.handleLIST(conn, activeHost, activePort)
+			if s.userStore != nil && authenticatedUser == nil {
+				s.send(conn, "530 Please login with USER and PASS")
+				continue
+			}
+			open, err := s.dataChannelOpener(pasvListener, activeHost, activePort, dataProtLevel)
+			pasvListener = nil
+			if err != nil {
+				s.send(conn, "425 Use PORT, EPRT, PASV or EPSV first")
+				continue
+			}
+			s.handleLIST(conn, open, authenticatedUser, currentDir)
+
+		case "NLST":
+			if s.userStore != nil && authenticatedUser == nil {
+				s.send(conn, "530 Please login with USER and PASS")
+				continue
+			}
+			open, err := s.dataChannelOpener(pasvListener, activeHost, activePort, dataProtLevel)
+			pasvListener = nil
+			if err != nil {
+				s.send(conn, "425 Use PORT, EPRT, PASV or EPSV first")
+				continue
+			}
+			s.handleNLST(conn, open, authenticatedUser, currentDir)
+
+		case "MLSD":
+			if s.userStore != nil && authenticatedUser == nil {
+				s.send(conn, "530 Please login with USER and PASS")
+				continue
+			}
+			open, err := s.dataChannelOpener(pasvListener, activeHost, activePort, dataProtLevel)
+			pasvListener = nil
+			if err != nil {
+				s.send(conn, "425 Use PORT, EPRT, PASV or EPSV first")
+				continue
+			}
+			s.handleMLSD(conn, open, authenticatedUser, currentDir)
+
+		case "MLST":
+			if s.userStore != nil && authenticatedUser == nil {
+				s.send(conn, "530 Please login with USER and PASS")
+				continue
+			}
+			arg := ""
+			if len(parts) > 1 {
+				arg = parts[1]
+			}
+			s.handleMLST(conn, arg, authenticatedUser, currentDir)
 
 		case "STOR":
 			if len(parts) < 2 {
 				s.send(conn, "501 Syntax error")
 				continue
 			}
-			s.handleSTOR(conn, parts[1], activeHost, activePort)
+			if s.userStore != nil && authenticatedUser == nil {
+				s.send(conn, "530 Please login with USER and PASS")
+				continue
+			}
+			open, err := s.dataChannelOpener(pasvListener, activeHost, activePort, dataProtLevel)
+			pasvListener = nil
+			if err != nil {
+				s.send(conn, "425 Use PORT, EPRT, PASV or EPSV first")
+				continue
+			}
+			s.handleSTOR(conn, parts[1], open, authenticatedUser, currentDir)
 
 		case "RETR":
 			// File retrieval not supported since files go directly to Paperless-ngx
@@ -293,8 +672,59 @@ func (s *FTPServer) handleConnection(conn net.Conn) {
 		case "FEAT":
 			s.send(conn, "211-Features:")
 			s.send(conn, " UTF8")
+			s.send(conn, " MLST Type*;Size*;Modify*;")
+			s.send(conn, " MLSD")
+			if s.tlsConfig != nil {
+				s.send(conn, " AUTH TLS")
+				s.send(conn, " AUTH SSL")
+				s.send(conn, " PBSZ")
+				s.send(conn, " PROT")
+			}
 			s.send(conn, "211 End")
 
+		case "AUTH":
+			if len(parts) < 2 || (strings.ToUpper(parts[1]) != "TLS" && strings.ToUpper(parts[1]) != "SSL") {
+				s.send(conn, "504 Command not implemented for that parameter")
+				continue
+			}
+			if s.tlsConfig == nil {
+				s.send(conn, "502 Command not implemented")
+				continue
+			}
+
+			s.send(conn, "234 AUTH "+strings.ToUpper(parts[1])+" successful")
+
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				logger.Warn("Explicit TLS handshake failed", "error", err)
+				return
+			}
+			conn = tlsConn
+
+		case "PBSZ":
+			// Protection buffer size is meaningless over TCP; always accept 0.
+			s.send(conn, "200 PBSZ=0")
+
+		case "PROT":
+			if len(parts) < 2 {
+				s.send(conn, "501 Syntax error")
+				continue
+			}
+			switch strings.ToUpper(parts[1]) {
+			case "C":
+				dataProtLevel = "C"
+				s.send(conn, "200 Protection level set to Clear")
+			case "P":
+				if s.tlsConfig == nil {
+					s.send(conn, "431 TLS not available")
+					continue
+				}
+				dataProtLevel = "P"
+				s.send(conn, "200 Protection level set to Private")
+			default:
+				s.send(conn, "504 Protection level not supported")
+			}
+
 		case "OPTS":
 			if len(parts) > 1 && strings.ToUpper(parts[1]) == "UTF8" {
 				s.send(conn, "200 UTF8 mode enabled")
@@ -353,7 +783,6 @@ func (s *FTPServer) handleConnection(conn net.Conn) {
 	}
 }
 
-
 func (s *FTPServer) send(conn net.Conn, msg string) {
 	conn.Write([]byte(msg + "\r\n"))
 	logger.Debug("FTP response", "message", msg)
@@ -364,6 +793,8 @@ func (s *FTPServer) readLine(conn net.Conn) (string, error) {
 	var line []byte
 
 	for {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+
 		n, err := conn.Read(buf)
 		if err != nil {
 			return "", err
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// uploadToPaperless streams src to the Paperless-ngx post_document API as a
+// multipart/form-data upload. Unlike buffering the whole file first, the
+// multipart body is produced incrementally through an io.Pipe: one goroutine
+// copies src into the form-file part while the HTTP request reads the other
+// end of the pipe, so memory use stays proportional to one copy buffer
+// rather than the file size. Returns the number of bytes copied from src,
+// which is reported even on error to aid debugging partial transfers.
+//
+// metadata carries the uploading user's default (or CWD-mapped) Paperless
+// metadata; its zero value uploads the document with no extra fields, same
+// as an unauthenticated server.
+func (s *FTPServer) uploadToPaperless(ctx context.Context, filename string, src io.Reader, username, apiToken string, metadata UserMetadata) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	var bytesCopied int64
+	copyDone := make(chan error, 1)
+
+	go func() {
+		if err := writeMetadataFields(mw, filename, username, metadata); err != nil {
+			pw.CloseWithError(err)
+			copyDone <- err
+			return
+		}
+
+		fw, err := mw.CreateFormFile("document", filepath.Base(filename))
+		if err != nil {
+			pw.CloseWithError(err)
+			copyDone <- err
+			return
+		}
+
+		n, err := io.Copy(fw, src)
+		bytesCopied = n
+		if err != nil {
+			// The data connection was aborted mid-transfer; tear down the
+			// pipe and cancel the in-flight HTTP request so Paperless
+			// doesn't silently accept a truncated document.
+			pw.CloseWithError(err)
+			cancel()
+			copyDone <- err
+			return
+		}
+
+		if err := mw.Close(); err != nil {
+			pw.CloseWithError(err)
+			copyDone <- err
+			return
+		}
+
+		copyDone <- pw.Close()
+	}()
+
+	body := &trackedBody{r: pr}
+
+	url := fmt.Sprintf("%s/api/documents/post_document/", strings.TrimRight(s.paperlessURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	token := s.paperlessToken
+	if apiToken != "" {
+		token = apiToken
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+
+	resp, err := defaultRetryPacer.Do(ctx, s.httpClient, req, body)
+
+	// Wait for the copy goroutine so bytesCopied is safe to read and so a
+	// copy error isn't masked by a request that merely failed because the
+	// pipe closed underneath it.
+	copyErr := <-copyDone
+
+	if err != nil {
+		return bytesCopied, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if copyErr != nil {
+		return bytesCopied, fmt.Errorf("failed to stream file to paperless: %w", copyErr)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return bytesCopied, fmt.Errorf("paperless API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	logger.Info("Document uploaded to Paperless-ngx", "filename", filename, "status", resp.StatusCode, "bytes", bytesCopied)
+	return bytesCopied, nil
+}
+
+// writeMetadataFields adds the per-user Paperless-ngx metadata fields ahead
+// of the document part, as post_document expects form fields before the
+// file they apply to. Unset metadata is simply omitted, so an unauthenticated
+// upload (the zero UserMetadata) adds nothing beyond the document itself.
+func writeMetadataFields(mw *multipart.Writer, filename, username string, metadata UserMetadata) error {
+	fields := map[string]string{}
+
+	if metadata.CorrespondentID != nil {
+		fields["correspondent"] = fmt.Sprintf("%d", *metadata.CorrespondentID)
+	}
+	if metadata.DocumentTypeID != nil {
+		fields["document_type"] = fmt.Sprintf("%d", *metadata.DocumentTypeID)
+	}
+	if len(metadata.TagIDs) > 0 {
+		fields["tags"] = tagIDsToCSV(metadata.TagIDs)
+	}
+	if metadata.TitleTemplate != "" {
+		fields["title"] = renderTitle(metadata.TitleTemplate, filename, username)
+	}
+	if metadata.StoragePath != "" {
+		fields["archive_serial_number"] = metadata.StoragePath
+	}
+
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			return fmt.Errorf("failed to write %s field: %w", name, err)
+		}
+	}
+
+	return nil
+}
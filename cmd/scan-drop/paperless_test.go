@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	m.Run()
+}
+
+func TestUploadToPaperlessStreamsFileAndMetadata(t *testing.T) {
+	var gotToken, gotTitle, gotFilename string
+	var gotBody []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken = r.Header.Get("Authorization")
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse multipart form: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		gotTitle = r.FormValue("title")
+
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			t.Errorf("failed to read document part: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		gotFilename = header.Filename
+		gotBody, _ = io.ReadAll(file)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &FTPServer{
+		paperlessURL:   server.URL,
+		paperlessToken: "default-token",
+		httpClient:     server.Client(),
+	}
+
+	content := "scanned document contents"
+	metadata := UserMetadata{TitleTemplate: "{{filename}}"}
+
+	n, err := s.uploadToPaperless(context.Background(), "invoice.pdf", strings.NewReader(content), "alice", "user-token", metadata)
+	if err != nil {
+		t.Fatalf("uploadToPaperless returned error: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("bytesCopied = %d, want %d", n, len(content))
+	}
+	if gotToken != "Token user-token" {
+		t.Errorf("Authorization header = %q, want the user's token, not the server default", gotToken)
+	}
+	if gotFilename != "invoice.pdf" {
+		t.Errorf("uploaded filename = %q, want invoice.pdf", gotFilename)
+	}
+	if string(gotBody) != content {
+		t.Errorf("uploaded body = %q, want %q", gotBody, content)
+	}
+	if gotTitle == "" {
+		t.Error("expected a rendered title field to be sent")
+	}
+}
+
+func TestUploadToPaperlessFallsBackToDefaultToken(t *testing.T) {
+	var gotToken string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken = r.Header.Get("Authorization")
+		// Drain the body so the client's Do doesn't see a connection reset.
+		if mr, err := r.MultipartReader(); err == nil {
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					break
+				}
+				io.Copy(io.Discard, part)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &FTPServer{
+		paperlessURL:   server.URL,
+		paperlessToken: "default-token",
+		httpClient:     server.Client(),
+	}
+
+	_, err := s.uploadToPaperless(context.Background(), "doc.pdf", strings.NewReader("x"), "", "", UserMetadata{})
+	if err != nil {
+		t.Fatalf("uploadToPaperless returned error: %v", err)
+	}
+	if gotToken != "Token default-token" {
+		t.Errorf("Authorization header = %q, want the server default token", gotToken)
+	}
+}
+
+func TestUploadToPaperlessPropagatesSourceReadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler may or may not get a complete request, depending on
+		// how far the copy got before failing; either way the client call
+		// below must report the src error, not hang.
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := &FTPServer{
+		paperlessURL: server.URL,
+		httpClient:   server.Client(),
+	}
+
+	_, err := s.uploadToPaperless(context.Background(), "doc.pdf", &failingReader{}, "", "", UserMetadata{})
+	if err == nil {
+		t.Fatal("expected an error when the source reader fails mid-upload")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestUploadToPaperlessNonOKStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte("bad metadata"))
+	}))
+	defer server.Close()
+
+	s := &FTPServer{
+		paperlessURL: server.URL,
+		httpClient:   server.Client(),
+	}
+
+	_, err := s.uploadToPaperless(context.Background(), "doc.pdf", strings.NewReader("x"), "", "", UserMetadata{})
+	if err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
+
+// multipartBoundaryContentType is a sanity check that writeMetadataFields
+// only ever writes the fields it's told to, so a zero-value UserMetadata
+// (unauthenticated upload) adds nothing beyond the document itself.
+func TestWriteMetadataFieldsOmitsUnsetFields(t *testing.T) {
+	var buf strings.Builder
+	mw := multipart.NewWriter(&buf)
+
+	if err := writeMetadataFields(mw, "doc.pdf", "alice", UserMetadata{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mw.Close()
+
+	if strings.Contains(buf.String(), "name=\"correspondent\"") {
+		t.Error("expected no correspondent field for zero-value metadata")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// dataChannelOpener abstracts how a data connection for the current
+// transfer is obtained, so handleLIST/handleSTOR don't need to know whether
+// the client is using PORT, EPRT, PASV or EPSV.
+type dataChannelOpener func() (net.Conn, error)
+
+// allocatePasvListener opens a TCP listener on the first free port in
+// [min, max], which is the range operators configure via
+// PASV_PORT_MIN/PASV_PORT_MAX so it can be punched through a firewall.
+func allocatePasvListener(min, max int) (net.Listener, int, error) {
+	if min <= 0 || max <= 0 || min > max {
+		return nil, 0, fmt.Errorf("invalid passive port range %d-%d", min, max)
+	}
+
+	for port := min; port <= max; port++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+		if err == nil {
+			return listener, port, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("no free port in passive range %d-%d", min, max)
+}
+
+// pasvOpener returns a dataChannelOpener that accepts a single connection on
+// listener, enforcing s.dataTimeout so a client that never connects can't
+// leak the goroutine, then applies TLS protection per dataProtLevel.
+func (s *FTPServer) pasvOpener(listener net.Listener, dataProtLevel string) dataChannelOpener {
+	return func() (net.Conn, error) {
+		defer listener.Close()
+
+		if tl, ok := listener.(*net.TCPListener); ok {
+			tl.SetDeadline(time.Now().Add(s.dataTimeout))
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil, fmt.Errorf("passive data connection not established: %w", err)
+		}
+
+		return s.wrapDataConn(conn, dataProtLevel)
+	}
+}
+
+// dataChannelOpener picks the right dataChannelOpener for the current
+// transfer: a pending PASV/EPSV listener takes priority over a PORT/EPRT
+// address, since a client that sent both most recently intends the latest
+// one.
+func (s *FTPServer) dataChannelOpener(pasvListener net.Listener, activeHost string, activePort int, dataProtLevel string) (dataChannelOpener, error) {
+	if pasvListener != nil {
+		return s.pasvOpener(pasvListener, dataProtLevel), nil
+	}
+
+	if activeHost != "" && activePort > 0 {
+		return s.activeOpener(activeHost, activePort, dataProtLevel), nil
+	}
+
+	return nil, fmt.Errorf("no data connection announced")
+}
+
+// activeOpener returns a dataChannelOpener that dials the host/port the
+// client announced via PORT/EPRT, bounded by s.dataTimeout, then applies TLS
+// protection per dataProtLevel.
+func (s *FTPServer) activeOpener(host string, port int, dataProtLevel string) dataChannelOpener {
+	return func() (net.Conn, error) {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), s.dataTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.wrapDataConn(conn, dataProtLevel)
+	}
+}
+
+// formatPasvReply renders the 227 reply for PASV: the advertised IP and port
+// encoded as h1,h2,h3,h4,p1,p2 per RFC 959. It errors rather than silently
+// advertising 0,0,0,0 when advertisedIP isn't a valid IPv4 address, since a
+// client that "successfully" enters passive mode against 0.0.0.0 can never
+// actually open the data connection.
+func formatPasvReply(advertisedIP string, port int) (string, error) {
+	ip := net.ParseIP(advertisedIP).To4()
+	if ip == nil {
+		return "", fmt.Errorf("PASV_ADVERTISED_IP is not set to a valid IPv4 address: %q", advertisedIP)
+	}
+
+	p1 := port / 256
+	p2 := port % 256
+
+	return fmt.Sprintf("227 Entering Passive Mode (%d,%d,%d,%d,%d,%d)", ip[0], ip[1], ip[2], ip[3], p1, p2), nil
+}
+
+// formatEpsvReply renders the 229 reply for EPSV per RFC 2428.
+func formatEpsvReply(port int) string {
+	return fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", port)
+}
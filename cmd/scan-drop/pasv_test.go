@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestFormatPasvReply(t *testing.T) {
+	cases := []struct {
+		name         string
+		advertisedIP string
+		port         int
+		want         string
+		wantErr      bool
+	}{
+		{"valid IPv4", "203.0.113.5", 0x1234, "227 Entering Passive Mode (203,0,113,5,18,52)", false},
+		{"empty", "", 2121, "", true},
+		{"unparsable", "not-an-ip", 2121, "", true},
+		{"IPv6 rejected", "2001:db8::1", 2121, "", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := formatPasvReply(tc.advertisedIP, tc.port)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("formatPasvReply(%q, %d) = %q, nil; want error", tc.advertisedIP, tc.port, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("formatPasvReply(%q, %d) unexpected error: %v", tc.advertisedIP, tc.port, err)
+			}
+			if got != tc.want {
+				t.Errorf("formatPasvReply(%q, %d) = %q, want %q", tc.advertisedIP, tc.port, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatEpsvReply(t *testing.T) {
+	got := formatEpsvReply(2121)
+	want := "229 Entering Extended Passive Mode (|||2121|)"
+	if got != want {
+		t.Errorf("formatEpsvReply(2121) = %q, want %q", got, want)
+	}
+}
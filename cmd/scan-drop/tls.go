@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// buildTLSConfig loads the server certificate (and optional client CA bundle
+// for mutual TLS) described by the config and returns a *tls.Config ready to
+// use for both the implicit-TLS listener and explicit AUTH TLS upgrades.
+//
+// It returns (nil, nil) when no certificate is configured, which callers
+// treat as "FTPS disabled".
+func buildTLSConfig(c *Config) (*tls.Config, error) {
+	if c.TLSCertFile == "" && c.TLSKeyFile == "" {
+		return nil, nil
+	}
+
+	if c.TLSCertFile == "" || c.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both TLS_CERT_FILE and TLS_KEY_FILE must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   c.TLSMinVersion,
+	}
+
+	if c.TLSClientCAFile != "" {
+		caBytes, err := os.ReadFile(c.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no valid certificates found in client CA bundle")
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// parseTLSVersion maps the short names accepted by FTPS_MIN_TLS_VERSION to
+// the corresponding crypto/tls constant. Unknown values fall back to TLS 1.2.
+func parseTLSVersion(s string) uint16 {
+	switch s {
+	case "1.0":
+		return tls.VersionTLS10
+	case "1.1":
+		return tls.VersionTLS11
+	case "1.2":
+		return tls.VersionTLS12
+	case "1.3":
+		return tls.VersionTLS13
+	default:
+		return tls.VersionTLS12
+	}
+}
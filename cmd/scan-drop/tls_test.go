@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert generates a self-signed ECDSA certificate/key pair (signed
+// by itself, so it can also act as its own CA for mutual-TLS tests) and
+// writes both as PEM files in a temp directory, returning their paths.
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "scan-drop-test"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	certOut, err := os.Create(certFile)
+	if err != nil {
+		t.Fatalf("failed to create cert file: %v", err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatalf("failed to write cert PEM: %v", err)
+	}
+
+	keyOut, err := os.Create(keyFile)
+	if err != nil {
+		t.Fatalf("failed to create key file: %v", err)
+	}
+	defer keyOut.Close()
+	if err := pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}); err != nil {
+		t.Fatalf("failed to write key PEM: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfigDisabledWhenUnset(t *testing.T) {
+	cfg, err := buildTLSConfig(&Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg != nil {
+		t.Fatalf("expected nil config when no cert is configured, got %+v", cfg)
+	}
+}
+
+func TestBuildTLSConfigRequiresBothCertAndKey(t *testing.T) {
+	if _, err := buildTLSConfig(&Config{TLSCertFile: "cert.pem"}); err == nil {
+		t.Fatal("expected error when TLSKeyFile is missing")
+	}
+	if _, err := buildTLSConfig(&Config{TLSKeyFile: "key.pem"}); err == nil {
+		t.Fatal("expected error when TLSCertFile is missing")
+	}
+}
+
+func TestBuildTLSConfigLoadsCertificate(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	cfg, err := buildTLSConfig(&Config{
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSMinVersion: tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Fatalf("expected 1 certificate, got %d", len(cfg.Certificates))
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		t.Fatalf("expected no client auth without a client CA bundle, got %v", cfg.ClientAuth)
+	}
+}
+
+func TestBuildTLSConfigMutualTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	cfg, err := buildTLSConfig(&Config{
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+		TLSClientCAFile: certFile, // self-signed cert doubles as its own CA
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("expected mutual TLS to be required, got %v", cfg.ClientAuth)
+	}
+	if cfg.ClientCAs == nil {
+		t.Fatal("expected ClientCAs pool to be populated")
+	}
+}
+
+func TestBuildTLSConfigInvalidClientCABundle(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	dir := t.TempDir()
+	badCA := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(badCA, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write bad CA file: %v", err)
+	}
+
+	if _, err := buildTLSConfig(&Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: badCA}); err == nil {
+		t.Fatal("expected error for a CA bundle with no valid certificates")
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	cases := map[string]uint16{
+		"1.0":   tls.VersionTLS10,
+		"1.1":   tls.VersionTLS11,
+		"1.2":   tls.VersionTLS12,
+		"1.3":   tls.VersionTLS13,
+		"bogus": tls.VersionTLS12,
+		"":      tls.VersionTLS12,
+	}
+	for input, want := range cases {
+		if got := parseTLSVersion(input); got != want {
+			t.Errorf("parseTLSVersion(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+// dialedPair opens a real loopback TCP connection (rather than net.Pipe,
+// whose unbuffered synchronous reads/writes make TLS handshakes needlessly
+// slow) and returns the accepted server-side conn and the dialed client-side
+// conn.
+func dialedPair(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	server = <-accepted
+	if server == nil {
+		t.Fatal("failed to accept connection")
+	}
+
+	return server, client
+}
+
+func TestWrapDataConnPassthroughWithoutProtP(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	tlsConfig, err := buildTLSConfig(&Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &FTPServer{tlsConfig: tlsConfig}
+
+	server, client := dialedPair(t)
+	defer client.Close()
+
+	wrapped, err := s.wrapDataConn(server, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer wrapped.Close()
+	if wrapped != server {
+		t.Fatal("expected passthrough connection when dataProtLevel isn't \"P\"")
+	}
+}
+
+func TestWrapDataConnUpgradesOnProtP(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	tlsConfig, err := buildTLSConfig(&Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &FTPServer{tlsConfig: tlsConfig}
+
+	serverConn, clientConn := dialedPair(t)
+	defer clientConn.Close()
+
+	clientDone := make(chan error, 1)
+	go func() {
+		clientTLS := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+		clientDone <- clientTLS.Handshake()
+	}()
+
+	wrapped, err := s.wrapDataConn(serverConn, "P")
+	if err != nil {
+		t.Fatalf("server handshake failed: %v", err)
+	}
+	defer wrapped.Close()
+
+	if _, ok := wrapped.(*tls.Conn); !ok {
+		t.Fatalf("expected a *tls.Conn, got %T", wrapped)
+	}
+	if err := <-clientDone; err != nil {
+		t.Fatalf("client handshake failed: %v", err)
+	}
+}
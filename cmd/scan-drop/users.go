@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// UserMetadata is the default Paperless-ngx metadata attached to documents
+// uploaded by a user, and/or the override applied when CWD matches a
+// configured path. Pointer fields distinguish "not set" from "set to zero"
+// so a path override only replaces what it explicitly specifies.
+type UserMetadata struct {
+	CorrespondentID *int   `json:"correspondent_id,omitempty" yaml:"correspondent_id,omitempty"`
+	DocumentTypeID  *int   `json:"document_type_id,omitempty" yaml:"document_type_id,omitempty"`
+	TagIDs          []int  `json:"tag_ids,omitempty" yaml:"tag_ids,omitempty"`
+	TitleTemplate   string `json:"title_template,omitempty" yaml:"title_template,omitempty"`
+	StoragePath     string `json:"storage_path,omitempty" yaml:"storage_path,omitempty"`
+}
+
+// User is one entry in the Users config: credentials for FTP login plus the
+// Paperless-ngx metadata to attach to everything they upload.
+type User struct {
+	Username     string       `json:"username" yaml:"username"`
+	PasswordHash string       `json:"password_hash" yaml:"password_hash"`
+	APIToken     string       `json:"api_token,omitempty" yaml:"api_token,omitempty"`
+	Metadata     UserMetadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// usersFile is the on-disk shape of the Users config: a list of users plus
+// an optional map of CWD path prefixes to metadata overrides (e.g.
+// "/invoices/acme" -> tag=invoices, correspondent=acme), matching how
+// scanner-button destinations are typically organized.
+type usersFile struct {
+	Users []User                  `json:"users" yaml:"users"`
+	Paths map[string]UserMetadata `json:"paths,omitempty" yaml:"paths,omitempty"`
+}
+
+// UserStore holds the loaded Users config and answers authentication and
+// metadata-resolution questions for authenticated sessions.
+type UserStore struct {
+	users map[string]*User
+	paths map[string]UserMetadata
+}
+
+// LoadUserStore reads a Users config from path, choosing JSON or YAML based
+// on the file extension.
+func LoadUserStore(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var parsed usersFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse users file as YAML: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse users file as JSON: %w", err)
+		}
+	}
+
+	return newUserStore(parsed), nil
+}
+
+// LoadUserStoreFromJSON builds a UserStore from an inline JSON string, for
+// deployments that prefer to encode the Users config directly into an
+// environment variable rather than mounting a file.
+func LoadUserStoreFromJSON(encoded string) (*UserStore, error) {
+	var parsed usersFile
+	if err := json.Unmarshal([]byte(encoded), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse users JSON: %w", err)
+	}
+
+	return newUserStore(parsed), nil
+}
+
+func newUserStore(parsed usersFile) *UserStore {
+	store := &UserStore{
+		users: make(map[string]*User, len(parsed.Users)),
+		paths: parsed.Paths,
+	}
+
+	for i := range parsed.Users {
+		u := parsed.Users[i]
+		store.users[u.Username] = &u
+	}
+
+	return store
+}
+
+// Authenticate checks password against the bcrypt hash configured for
+// username and returns the matching User on success.
+func (s *UserStore) Authenticate(username, password string) (*User, bool) {
+	user, ok := s.users[username]
+	if !ok {
+		return nil, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, false
+	}
+
+	return user, true
+}
+
+// ResolveMetadata merges a user's default metadata with any path override
+// that matches cwd, using the longest matching configured prefix. Fields
+// the path override leaves unset fall back to the user's defaults.
+func (s *UserStore) ResolveMetadata(user *User, cwd string) UserMetadata {
+	metadata := user.Metadata
+
+	var bestPrefix string
+	var bestOverride UserMetadata
+	found := false
+
+	for prefix, override := range s.paths {
+		if cwd != prefix && !strings.HasPrefix(cwd, prefix+"/") {
+			continue
+		}
+		if !found || len(prefix) > len(bestPrefix) {
+			bestPrefix = prefix
+			bestOverride = override
+			found = true
+		}
+	}
+
+	if !found {
+		return metadata
+	}
+
+	if bestOverride.CorrespondentID != nil {
+		metadata.CorrespondentID = bestOverride.CorrespondentID
+	}
+	if bestOverride.DocumentTypeID != nil {
+		metadata.DocumentTypeID = bestOverride.DocumentTypeID
+	}
+	if len(bestOverride.TagIDs) > 0 {
+		metadata.TagIDs = bestOverride.TagIDs
+	}
+	if bestOverride.TitleTemplate != "" {
+		metadata.TitleTemplate = bestOverride.TitleTemplate
+	}
+	if bestOverride.StoragePath != "" {
+		metadata.StoragePath = bestOverride.StoragePath
+	}
+
+	return metadata
+}
+
+// renderTitle expands the placeholders supported in a title template:
+// {filename} (base name of the uploaded file) and {user} (FTP username).
+func renderTitle(template, filename, username string) string {
+	title := strings.ReplaceAll(template, "{filename}", filepath.Base(filename))
+	title = strings.ReplaceAll(title, "{user}", username)
+	return title
+}
+
+// tagIDsToCSV renders tag IDs the way Paperless-ngx's post_document form
+// field expects them: a comma-separated list.
+func tagIDsToCSV(tagIDs []int) string {
+	parts := make([]string, len(tagIDs))
+	for i, id := range tagIDs {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
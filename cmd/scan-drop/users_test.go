@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestResolveMetadataPathPrefixMatching(t *testing.T) {
+	correspondent := 7
+	invoices := 42
+
+	store := &UserStore{
+		paths: map[string]UserMetadata{
+			"/invoices": {CorrespondentID: &invoices},
+		},
+	}
+	user := &User{Metadata: UserMetadata{CorrespondentID: &correspondent}}
+
+	cases := []struct {
+		name         string
+		cwd          string
+		wantOverride bool
+	}{
+		{"exact match", "/invoices", true},
+		{"nested match", "/invoices/acme", true},
+		{"unrelated sibling with shared prefix", "/invoicesArchive/foo", false},
+		{"unrelated sibling, no slash", "/invoicesArchive", false},
+		{"no match", "/other", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := store.ResolveMetadata(user, tc.cwd)
+			gotOverride := got.CorrespondentID != nil && *got.CorrespondentID == invoices
+			if gotOverride != tc.wantOverride {
+				t.Errorf("ResolveMetadata(%q) override = %v, want %v", tc.cwd, gotOverride, tc.wantOverride)
+			}
+		})
+	}
+}